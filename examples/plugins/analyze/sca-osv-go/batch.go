@@ -0,0 +1,242 @@
+package main
+
+// Batch resolution against OSV's /v1/querybatch endpoint: one round trip
+// for up to 1000 package queries, then a bounded worker pool to fetch the
+// full entry for every vuln ID that came back from /v1/vulns/{id}.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// osvBatchLimit is the max queries per /v1/querybatch call, per the OSV API.
+const osvBatchLimit = 1000
+
+type osvBatchQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version   string `json:"version,omitempty"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+type osvBatchResult struct {
+	Vulns []struct {
+		ID string `json:"id"`
+	} `json:"vulns"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+type osvBatchResponse struct {
+	Results []osvBatchResult `json:"results"`
+}
+
+// ByPackages resolves many packages in as few round trips as possible: a
+// querybatch pass to find the matching vuln IDs, then a bounded worker pool
+// fetching the full Entry for each distinct ID.
+func (s *httpOSVSource) ByPackages(ctx context.Context, reqs []PackageRequest, workers int) (map[string][]Entry, error) {
+	if workers <= 0 {
+		workers = defaultOSVWorkers
+	}
+
+	idsByReq, err := s.queryBatch(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueIDs := map[string]struct{}{}
+	for _, ids := range idsByReq {
+		for _, id := range ids {
+			uniqueIDs[id] = struct{}{}
+		}
+	}
+
+	entriesByID, err := s.fetchEntries(ctx, uniqueIDs, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]Entry, len(reqs))
+	for i, req := range reqs {
+		key := packageKey(req.Ecosystem, req.Name, req.Version)
+		var entries []Entry
+		for _, id := range idsByReq[i] {
+			if e, ok := entriesByID[id]; ok {
+				entries = append(entries, e)
+			}
+		}
+		// Every request gets a key, even with no vulns, so callers can
+		// cache "nothing found" as well as hits.
+		out[key] = append(out[key], entries...)
+	}
+	return out, nil
+}
+
+// queryBatch returns, for each request (by index), the IDs of vulns that
+// matched, paging through next_page_token until every query is exhausted.
+// Only queries that still have a page left are resent on each round — a
+// query that already finished drops out, since resending it with a fresh
+// (empty) page token would restart it from page 1 and duplicate its IDs.
+func (s *httpOSVSource) queryBatch(ctx context.Context, reqs []PackageRequest) ([][]string, error) {
+	ids := make([][]string, len(reqs))
+	for start := 0; start < len(reqs); start += osvBatchLimit {
+		end := start + osvBatchLimit
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		chunk := reqs[start:end]
+
+		// active maps each in-flight query back to its index within chunk.
+		active := make([]int, len(chunk))
+		for i := range chunk {
+			active[i] = i
+		}
+		pageTokens := make([]string, len(chunk))
+
+		for len(active) > 0 {
+			queries := make([]osvBatchQuery, len(active))
+			for q, i := range active {
+				r := chunk[i]
+				queries[q].Package.Name = r.Name
+				queries[q].Package.Ecosystem = r.Ecosystem
+				queries[q].Version = r.Version
+				queries[q].PageToken = pageTokens[i]
+			}
+
+			res, err := s.postBatch(ctx, queries)
+			if err != nil {
+				return nil, err
+			}
+
+			var next []int
+			for q, i := range active {
+				if q >= len(res.Results) {
+					continue
+				}
+				r := res.Results[q]
+				for _, v := range r.Vulns {
+					ids[start+i] = append(ids[start+i], v.ID)
+				}
+				if r.NextPageToken != "" {
+					pageTokens[i] = r.NextPageToken
+					next = append(next, i)
+				}
+			}
+			active = next
+			if len(active) > 0 && ctx.Err() != nil {
+				return ids, ctx.Err()
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (s *httpOSVSource) postBatch(ctx context.Context, queries []osvBatchQuery) (osvBatchResponse, error) {
+	var res osvBatchResponse
+	body, _ := json.Marshal(map[string]interface{}{"queries": queries})
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.base+"/querybatch", bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return res, &osvHTTPError{StatusCode: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// fetchEntries resolves the full Entry for each ID using a bounded pool of
+// workers, all watching the same ctx.Done() so a blown deadline stops every
+// in-flight request at once rather than trickling out one timeout at a time.
+func (s *httpOSVSource) fetchEntries(ctx context.Context, ids map[string]struct{}, workers int) (map[string]Entry, error) {
+	jobs := make(chan string)
+	type result struct {
+		id    string
+		entry Entry
+		err   error
+	}
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				e, err := s.getVuln(ctx, id)
+				results <- result{id: id, entry: e, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for id := range ids {
+			select {
+			case jobs <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	entries := make(map[string]Entry, len(ids))
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		entries[r.id] = r.entry
+	}
+	// Fewer entries than IDs with no worker error means the feeder stopped
+	// on ctx.Done before handing out every ID.
+	if firstErr == nil && len(entries) < len(ids) {
+		firstErr = ctx.Err()
+	}
+	return entries, firstErr
+}
+
+func (s *httpOSVSource) getVuln(ctx context.Context, id string) (Entry, error) {
+	var e Entry
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", s.base+"/vulns/"+id, nil)
+	if err != nil {
+		return e, err
+	}
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return e, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return e, &osvHTTPError{StatusCode: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return e, err
+	}
+	return e, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+// Error aggregation for file.analyze: a file that can't be read, parsed, or
+// queried against OSV no longer drops the rest of the batch on the floor or
+// vanishes without a trace. Each failure is recorded alongside whatever
+// findings did complete, and the caller can opt into "strict" handling to
+// turn that into a JSON-RPC error instead.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// analyzeError describes one failure encountered while handling a
+// file.analyze request.
+type analyzeError struct {
+	File    string `json:"file"`
+	Stage   string `json:"stage"` // "read" | "parse" | "query"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Stages a file can fail at.
+const (
+	stageRead  = "read"
+	stageParse = "parse"
+	stageQuery = "query"
+)
+
+// Stable error codes surfaced in analyzeError.Code.
+const (
+	codeFileReadFailed = "FILE_READ_FAILED"
+	codeParseFailed    = "PARSE_FAILED"
+	codeOSVHTTPError   = "OSV_HTTP_ERROR"
+	codeOSVRateLimited = "OSV_RATE_LIMITED"
+	codeOSVTimeout     = "OSV_TIMEOUT"
+)
+
+// analyzeResult is the file.analyze result envelope: partial success is the
+// norm, so findings and errors are reported side by side rather than one
+// replacing the other.
+type analyzeResult struct {
+	Findings []findingOut   `json:"findings"`
+	Errors   []analyzeError `json:"errors,omitempty"`
+}
+
+// osvHTTPError reports a non-2xx response from an OSV-compatible HTTP API,
+// so callers can tell rate limiting and server errors apart from a plain
+// network failure.
+type osvHTTPError struct {
+	StatusCode int
+}
+
+func (e *osvHTTPError) Error() string {
+	return fmt.Sprintf("osv: unexpected status %d", e.StatusCode)
+}
+
+// classifyOSVError maps an error from resolveEntries to a stable
+// analyzeError code: a blown deadline becomes OSV_TIMEOUT, a 429 becomes
+// OSV_RATE_LIMITED, and any other non-2xx or transport failure becomes
+// OSV_HTTP_ERROR.
+func classifyOSVError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return codeOSVTimeout
+	}
+	var httpErr *osvHTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == 429 {
+		return codeOSVRateLimited
+	}
+	return codeOSVHTTPError
+}
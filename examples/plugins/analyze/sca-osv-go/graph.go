@@ -0,0 +1,257 @@
+package main
+
+// GUAC-inspired dependency graph: a "file.graph" JSON-RPC method that
+// returns, per analyzed file, package/vulnerability nodes and depends_on /
+// affected_by edges, mirroring GUAC's nouns (packages, vulnerabilities) and
+// verbs (IsDependency, CertifyVuln) without taking on GUAC itself.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// graphNode is either a package (purl-identified) or a vulnerability.
+type graphNode struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"` // "package" | "vulnerability" | "root"
+	Name      string   `json:"name,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Ecosystem string   `json:"ecosystem,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+}
+
+// graphEdge is a directed relation between two node IDs.
+type graphEdge struct {
+	Type string `json:"type"` // "depends_on" | "affected_by"
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// fileGraph is the dependency subgraph extracted from one analyzed file.
+type fileGraph struct {
+	File  string      `json:"file"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// graphCache holds the graph built while parsing a file in a prior
+// file.analyze call, keyed by file path, so file.graph doesn't have to
+// re-parse a file it has already seen.
+var graphCache = map[string]fileGraph{}
+
+// purl renders the simplified "pkg:<ecosystem>/<name>@<version>" form used
+// throughout this plugin's graph output.
+func purl(ecosystem, name, version string) string {
+	return fmt.Sprintf("pkg:%s/%s@%s", ecosystem, name, version)
+}
+
+// graphBuilder accumulates nodes/edges while deduping by ID.
+type graphBuilder struct {
+	nodes    []graphNode
+	edges    []graphEdge
+	nodeSeen map[string]bool
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{nodeSeen: map[string]bool{}}
+}
+
+func (b *graphBuilder) addNode(n graphNode) {
+	if b.nodeSeen[n.ID] {
+		return
+	}
+	b.nodeSeen[n.ID] = true
+	b.nodes = append(b.nodes, n)
+}
+
+func (b *graphBuilder) addEdge(edgeType, from, to string) {
+	b.edges = append(b.edges, graphEdge{Type: edgeType, From: from, To: to})
+}
+
+func (b *graphBuilder) addPackage(ecosystem, name, version string) string {
+	id := purl(ecosystem, name, version)
+	b.addNode(graphNode{ID: id, Type: "package", Name: name, Version: version, Ecosystem: ecosystem})
+	return id
+}
+
+// buildFileGraph extracts the dependency structure for one file: direct
+// edges from a synthetic root node for manifests (requirements.txt,
+// go.mod), and true transitive depends_on edges for lockfiles that record
+// their own resolution tree (package-lock.json, Cargo.lock).
+func buildFileGraph(path, name string, data []byte, hits []depHit) fileGraph {
+	switch name {
+	case "package-lock.json":
+		return graphFromPackageLock(path, data, hits)
+	case "Cargo.lock":
+		return graphFromCargoLock(path, data, hits)
+	default:
+		return graphFromDirectHits(path, hits)
+	}
+}
+
+// graphFromDirectHits builds a flat, direct-only graph: root -> each
+// parsed dependency. Used for manifests that don't carry a resolution tree
+// (requirements.txt, go.mod) and as the fallback for anything else.
+func graphFromDirectHits(path string, hits []depHit) fileGraph {
+	b := newGraphBuilder()
+	rootID := "root:" + path
+	b.addNode(graphNode{ID: rootID, Type: "root", Name: path})
+	for _, h := range hits {
+		pkgID := b.addPackage(h.Dep.Ecosystem, h.Dep.Name, h.Dep.Version)
+		b.addEdge("depends_on", rootID, pkgID)
+	}
+	return fileGraph{File: path, Nodes: b.nodes, Edges: b.edges}
+}
+
+// graphFromPackageLock builds transitive depends_on edges from a npm v7+
+// package-lock.json's "packages" map: each entry's own "dependencies"
+// object names its direct children, which are resolved by name to the
+// top-level "node_modules/<name>" entry; copies nested under another
+// package's node_modules are not consulted for that resolution.
+func graphFromPackageLock(path string, data []byte, hits []depHit) fileGraph {
+	b := newGraphBuilder()
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return graphFromDirectHits(path, hits)
+	}
+	pkgs, ok := raw["packages"].(map[string]any)
+	if !ok {
+		return graphFromDirectHits(path, hits)
+	}
+
+	// name -> version, to resolve a "dependencies" reference to the
+	// version npm actually installed for it.
+	versionByName := map[string]string{}
+	for p, v := range pkgs {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		ver, _ := obj["version"].(string)
+		if ver == "" {
+			continue
+		}
+		if p == "" {
+			continue
+		}
+		versionByName[strings.TrimPrefix(p, "node_modules/")] = ver
+	}
+
+	rootID := "root:" + path
+	b.addNode(graphNode{ID: rootID, Type: "root", Name: path})
+
+	for p, v := range pkgs {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		var fromID string
+		if p == "" {
+			fromID = rootID
+		} else {
+			name := strings.TrimPrefix(p, "node_modules/")
+			ver, _ := obj["version"].(string)
+			fromID = b.addPackage("npm", name, ver)
+		}
+
+		deps, _ := obj["dependencies"].(map[string]any)
+		for depName := range deps {
+			ver, ok := versionByName[depName]
+			if !ok {
+				continue
+			}
+			toID := b.addPackage("npm", depName, ver)
+			b.addEdge("depends_on", fromID, toID)
+		}
+	}
+	return fileGraph{File: path, Nodes: b.nodes, Edges: b.edges}
+}
+
+// graphFromCargoLock builds transitive depends_on edges from Cargo.lock's
+// per-package "dependencies" array, resolving each referenced name to the
+// matching [[package]] entry.
+func graphFromCargoLock(path string, data []byte, hits []depHit) fileGraph {
+	type cargoPkg struct {
+		name, version string
+		deps          []string
+	}
+	var pkgs []cargoPkg
+
+	for _, block := range strings.Split(string(data), "[[package]]") {
+		var cur cargoPkg
+		inDeps := false
+		for _, rawLine := range strings.Split(block, "\n") {
+			line := strings.TrimSpace(rawLine)
+			switch {
+			case strings.HasPrefix(line, "name = "):
+				cur.name = strings.Trim(line[len("name = "):], "\" ")
+			case strings.HasPrefix(line, "version = "):
+				cur.version = strings.Trim(line[len("version = "):], "\" ")
+			case strings.HasPrefix(line, "dependencies = ["):
+				inDeps = true
+				line = strings.TrimPrefix(line, "dependencies = [")
+				fallthrough
+			case inDeps:
+				for _, tok := range strings.Split(line, ",") {
+					tok = strings.Trim(tok, "[] \"")
+					if tok == "" {
+						continue
+					}
+					// Entries may be "name" or "name version"; keep the name.
+					cur.deps = append(cur.deps, strings.Fields(tok)[0])
+				}
+				if strings.Contains(line, "]") {
+					inDeps = false
+				}
+			}
+		}
+		if cur.name != "" {
+			pkgs = append(pkgs, cur)
+		}
+	}
+
+	b := newGraphBuilder()
+	byName := map[string]string{}
+	for _, p := range pkgs {
+		byName[p.name] = p.version
+	}
+	for _, p := range pkgs {
+		fromID := b.addPackage("crates.io", p.name, p.version)
+		for _, depName := range p.deps {
+			ver, ok := byName[depName]
+			if !ok {
+				continue
+			}
+			toID := b.addPackage("crates.io", depName, ver)
+			b.addEdge("depends_on", fromID, toID)
+		}
+	}
+	if len(b.nodes) == 0 {
+		return graphFromDirectHits(path, hits)
+	}
+	return fileGraph{File: path, Nodes: b.nodes, Edges: b.edges}
+}
+
+// addVulnEdges augments a cached file graph with an affected_by edge (and
+// vulnerability node) for one dependency hit's matched OSV entry. Called
+// once OSV resolution has happened, since the structural graph is built
+// before any vuln is known.
+func addVulnEdges(g *fileGraph, eco, name, version string, entry Entry, severity string) {
+	b := &graphBuilder{nodeSeen: map[string]bool{}}
+	for _, n := range g.Nodes {
+		b.nodeSeen[n.ID] = true
+	}
+	b.nodes = g.Nodes
+	b.edges = g.Edges
+
+	pkgID := b.addPackage(eco, name, version)
+	vulnID := entry.ID
+	b.addNode(graphNode{ID: vulnID, Type: "vulnerability", Aliases: entry.Aliases, Severity: severity})
+	b.addEdge("affected_by", pkgID, vulnID)
+
+	g.Nodes = b.nodes
+	g.Edges = b.edges
+}
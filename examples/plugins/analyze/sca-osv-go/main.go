@@ -6,10 +6,10 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -40,6 +40,23 @@ type fileSpec struct {
 
 type fileAnalyzeParams struct {
 	Files []fileSpec `json:"files"`
+	// DeadlineMS, when set, bounds how long OSV lookups for this request may
+	// take; in-flight HTTP calls are cancelled once it elapses.
+	DeadlineMS *int64 `json:"deadline_ms,omitempty"`
+	// Strict, when true, promotes any collected Errors into a JSON-RPC error
+	// response instead of returning them alongside partial findings.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// pluginInitParams carries the engine-supplied plugin configuration.
+type pluginInitParams struct {
+	// OSVDB points the plugin at an OSV source: a bare path or "file://" URL
+	// for a mirrored database, or an "http(s)://" URL for a live API. Falls
+	// back to the OSV_DB env var, then api.osv.dev, when empty.
+	OSVDB string `json:"osv_db"`
+	// OSVWorkers bounds the concurrent vuln-detail fetches issued per
+	// file.analyze call. Defaults to defaultOSVWorkers when zero.
+	OSVWorkers int `json:"osv_workers"`
 }
 
 // dependency holds package metadata for OSV queries.
@@ -53,43 +70,57 @@ type depHit struct {
 	Dep     dependency
 	Line    int
 	Excerpt string
+	// Spec is the raw, unresolved version spec as written in the manifest
+	// (e.g. "^1.2.3"). The package.json and requirements.txt parsers set it
+	// for every hit; resolveVersion reads it only when Unpinned is true.
+	Spec     string
+	Unpinned bool
 }
 
-// In-memory OSV query cache per (ecosystem|name|version)
-var vulnCache = map[string][]string{}
+// osvSource is the active OSVSource, configured at plugin.init time from
+// the "osv_db" param or the OSV_DB env var. Defaults to api.osv.dev.
+var osvSource OSVSource = NewSource(os.Getenv("OSV_DB"))
 
-func cacheKey(dep dependency) string {
-	return dep.Ecosystem + "|" + dep.Name + "|" + dep.Version
-}
+// osvWorkers bounds the concurrent vuln-detail fetches issued per
+// file.analyze call; overridden by plugin.init's "osv_workers" param.
+var osvWorkers = defaultOSVWorkers
 
-func queryOSVCached(dep dependency) ([]string, error) {
-	if v, ok := vulnCache[cacheKey(dep)]; ok {
-		return v, nil
-	}
-	ids, err := queryOSV(dep)
-	if err == nil {
-		vulnCache[cacheKey(dep)] = ids
-	}
-	return ids, err
+// In-memory OSV query cache per (ecosystem|name|version), shared across
+// file.analyze calls so repeated dependencies aren't re-queried. Unpinned
+// dependencies resolved via range matching are cached under an empty
+// version, holding every known vuln for the package.
+var vulnCache = map[string][]Entry{}
+
+// fileHits pairs one analyzed file with the dependencies parsed out of it.
+type fileHits struct {
+	path string
+	hits []depHit
 }
 
 // parsePackageJSON extracts dependencies from a package.json (dependencies + devDependencies)
-func parsePackageJSON(data []byte) []depHit {
+func parsePackageJSON(data []byte) ([]depHit, error) {
 	type pkg struct {
 		Dependencies    map[string]string `json:"dependencies"`
 		DevDependencies map[string]string `json:"devDependencies"`
 	}
 	var p pkg
 	if err := json.Unmarshal(data, &p); err != nil {
-		return nil
+		return nil, err
 	}
 	var hits []depHit
-	add := func(name, ver string) {
-		ver = strings.TrimSpace(ver)
+	add := func(name, spec string) {
+		spec = strings.TrimSpace(spec)
+		ver := spec
 		ver = strings.TrimPrefix(ver, "^")
 		ver = strings.TrimPrefix(ver, ">=")
 		ver = strings.TrimPrefix(ver, "~")
-		hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: 1, Excerpt: name + ": " + ver})
+		hits = append(hits, depHit{
+			Dep:      dependency{Name: name, Version: ver, Ecosystem: "npm"},
+			Spec:     spec,
+			Unpinned: spec != ver,
+			Line:     lineOf(data, "\""+name+"\""),
+			Excerpt:  name + ": " + spec,
+		})
 	}
 	for k, v := range p.Dependencies {
 		add(k, v)
@@ -97,14 +128,7 @@ func parsePackageJSON(data []byte) []depHit {
 	for k, v := range p.DevDependencies {
 		add(k, v)
 	}
-	return hits
-}
-
-// osvResponse mirrors the subset of OSV response we need.
-type osvResponse struct {
-	Vulns []struct {
-		ID string `json:"id"`
-	} `json:"vulns"`
+	return hits, nil
 }
 
 // findingOut matches the Rust engine Finding schema (subset used).
@@ -143,7 +167,11 @@ func readFile(f fileSpec) ([]byte, error) {
 	return nil, fmt.Errorf("no data")
 }
 
-func parseRequirements(data []byte) []depHit {
+// requirementOperators lists PEP 508 version comparisons in the order we
+// probe for them; "==" must come before the shorter single-char operators.
+var requirementOperators = []string{"==", "~=", ">=", "<=", "!=", ">", "<"}
+
+func parseRequirements(data []byte) ([]depHit, error) {
 	var hits []depHit
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	lineNum := 0
@@ -153,19 +181,32 @@ func parseRequirements(data []byte) []depHit {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		parts := strings.Split(line, "==")
-		if len(parts) == 2 {
-			hits = append(hits, depHit{
-				Dep:     dependency{Name: parts[0], Version: parts[1], Ecosystem: "PyPI"},
-				Line:    lineNum,
-				Excerpt: line,
-			})
+
+		var name, op, spec string
+		for _, candidate := range requirementOperators {
+			if idx := strings.Index(line, candidate); idx >= 0 {
+				name = strings.TrimSpace(line[:idx])
+				op = candidate
+				spec = strings.TrimSpace(line[idx+len(candidate):])
+				break
+			}
 		}
+		if name == "" {
+			continue
+		}
+
+		hits = append(hits, depHit{
+			Dep:      dependency{Name: name, Version: spec, Ecosystem: "PyPI"},
+			Spec:     op + spec,
+			Unpinned: op != "==",
+			Line:     lineNum,
+			Excerpt:  line,
+		})
 	}
-	return hits
+	return hits, scanner.Err()
 }
 
-func parseGoMod(data []byte) []depHit {
+func parseGoMod(data []byte) ([]depHit, error) {
 	var hits []depHit
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	inBlock := false
@@ -198,8 +239,7 @@ func parseGoMod(data []byte) []depHit {
 			})
 		}
 	}
-	return hits
-	
+	return hits, scanner.Err()
 }
 
 func severityFromID(id string) string {
@@ -233,45 +273,11 @@ func ruleForEco(eco string) string {
 	}
 }
 
-func queryOSV(dep dependency) ([]string, error) {
-	payload := map[string]interface{}{
-		"package": map[string]string{
-			"name":      dep.Name,
-			"ecosystem": dep.Ecosystem,
-		},
-		"version": dep.Version,
-	}
-	body, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.osv.dev/v1/query", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var res osvResponse
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return nil, err
-	}
-
-	var ids []string
-	for _, v := range res.Vulns {
-		ids = append(ids, v.ID)
-	}
-	return ids, nil
-}
-
 // parsePackageLockJSON extracts dependencies from package-lock.json
-func parsePackageLockJSON(data []byte) []depHit {
+func parsePackageLockJSON(data []byte) ([]depHit, error) {
 	var raw map[string]any
 	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil
+		return nil, err
 	}
 	var hits []depHit
 	// dependencies map style
@@ -279,7 +285,7 @@ func parsePackageLockJSON(data []byte) []depHit {
 		for name, v := range deps {
 			if obj, ok := v.(map[string]any); ok {
 				if ver, ok := obj["version"].(string); ok {
-					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: 1, Excerpt: name + ": " + ver})
+					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: lineOf(data, "\""+name+"\""), Excerpt: name + ": " + ver})
 				}
 			}
 		}
@@ -293,54 +299,58 @@ func parsePackageLockJSON(data []byte) []depHit {
 			name := strings.TrimPrefix(path, "node_modules/")
 			if obj, ok := v.(map[string]any); ok {
 				if ver, ok := obj["version"].(string); ok {
-					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: 1, Excerpt: name + ": " + ver})
+					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: lineOf(data, "\""+name+"\""), Excerpt: name + ": " + ver})
 				}
 			}
 		}
 	}
-	return hits
+	return hits, nil
 }
 
 // parseCargoLock reads Cargo.lock TOML-like format
-func parseCargoLock(data []byte) []depHit {
+func parseCargoLock(data []byte) ([]depHit, error) {
 	var hits []depHit
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	inPkg := false
+	line := 0
+	pkgLine := 0
 	name := ""
 	ver := ""
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "[[package]]" {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "[[package]]" {
 			inPkg = true
+			pkgLine = line
 			name = ""
 			ver = ""
 			continue
 		}
-		if strings.HasPrefix(line, "[") && !strings.HasPrefix(line, "[[package]]") {
+		if strings.HasPrefix(raw, "[") && !strings.HasPrefix(raw, "[[package]]") {
 			inPkg = false
 		}
 		if !inPkg {
 			continue
 		}
-		if strings.HasPrefix(line, "name = ") {
-			name = strings.Trim(line[7:], "\" ")
-		} else if strings.HasPrefix(line, "version = ") {
-			ver = strings.Trim(line[10:], "\" ")
+		if strings.HasPrefix(raw, "name = ") {
+			name = strings.Trim(raw[7:], "\" ")
+		} else if strings.HasPrefix(raw, "version = ") {
+			ver = strings.Trim(raw[10:], "\" ")
 		}
 		if name != "" && ver != "" {
-			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "crates.io"}, Line: 1, Excerpt: name + " = " + ver})
+			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "crates.io"}, Line: pkgLine, Excerpt: name + " = " + ver})
 			name = ""
 			ver = ""
 		}
 	}
-	return hits
+	return hits, scanner.Err()
 }
 
 // parseComposerLock reads composer.lock JSON
-func parseComposerLock(data []byte) []depHit {
+func parseComposerLock(data []byte) ([]depHit, error) {
 	var raw map[string]any
 	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil
+		return nil, err
 	}
 	var hits []depHit
 	if pkgs, ok := raw["packages"].([]any); ok {
@@ -350,43 +360,49 @@ func parseComposerLock(data []byte) []depHit {
 				ver, _ := obj["version"].(string)
 				if name != "" && ver != "" {
 					ver = strings.TrimPrefix(ver, "v")
-					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "Packagist"}, Line: 1, Excerpt: name + ": " + ver})
+					hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "Packagist"}, Line: lineOf(data, "\""+name+"\""), Excerpt: name + ": " + ver})
 				}
 			}
 		}
 	}
-	return hits
+	return hits, nil
 }
 
 // parseGemfileLock reads Gemfile.lock
-func parseGemfileLock(data []byte) []depHit {
+func parseGemfileLock(data []byte) ([]depHit, error) {
 	var hits []depHit
 	re := regexp.MustCompile(`^\s{2}([A-Za-z0-9_\-]+) \(([^)]+)\)`) //   name (x.y.z)
 	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 		m := re.FindStringSubmatch(line)
 		if len(m) == 3 {
 			name := m[1]
 			ver := m[2]
-			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "RubyGems"}, Line: 1, Excerpt: strings.TrimSpace(line)})
+			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "RubyGems"}, Line: lineNum, Excerpt: strings.TrimSpace(line)})
 		}
 	}
-	return hits
+	return hits, scanner.Err()
 }
 
 // parsePomXML rudimentary parsing of pom.xml dependencies
-func parsePomXML(data []byte) []depHit {
+func parsePomXML(data []byte) ([]depHit, error) {
 	var hits []depHit
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	inDep := false
+	lineNum := 0
+	depLine := 0
 	group := ""
 	artifact := ""
 	version := ""
 	for scanner.Scan() {
+		lineNum++
 		line := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(line, "<dependency>") {
 			inDep = true
+			depLine = lineNum
 			group = ""
 			artifact = ""
 			version = ""
@@ -395,7 +411,7 @@ func parsePomXML(data []byte) []depHit {
 		if strings.HasPrefix(line, "</dependency>") {
 			if group != "" && artifact != "" && version != "" {
 				name := group + ":" + artifact
-				hits = append(hits, depHit{Dep: dependency{Name: name, Version: version, Ecosystem: "Maven"}, Line: 1, Excerpt: name + ":" + version})
+				hits = append(hits, depHit{Dep: dependency{Name: name, Version: version, Ecosystem: "Maven"}, Line: depLine, Excerpt: name + ":" + version})
 			}
 			inDep = false
 			continue
@@ -413,7 +429,7 @@ func parsePomXML(data []byte) []depHit {
 			version = strings.Trim(strings.TrimSuffix(strings.TrimPrefix(line, "<version>"), "</version>"), " ")
 		}
 	}
-	return hits
+	return hits, scanner.Err()
 }
 
 func main() {
@@ -425,9 +441,17 @@ func main() {
 		}
 		switch req.Method {
 		case "plugin.init":
+			var params pluginInitParams
+			_ = json.Unmarshal(req.Params, &params)
+			if params.OSVDB != "" {
+				osvSource = NewSource(params.OSVDB)
+			}
+			if params.OSVWorkers > 0 {
+				osvWorkers = params.OSVWorkers
+			}
 			send(req.ID, map[string]interface{}{
 				"ok":             true,
-				"capabilities":   []string{"analyze"},
+				"capabilities":   []string{"analyze", "graph"},
 				"plugin_version": "0.1.0",
 			}, nil)
 		case "file.analyze":
@@ -437,65 +461,163 @@ func main() {
 				continue
 			}
 
-			var findings []findingOut
+			ctx := context.Background()
+			if params.DeadlineMS != nil && *params.DeadlineMS > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(*params.DeadlineMS)*time.Millisecond)
+				defer cancel()
+			}
+
+			var perFile []fileHits
+			var errs []analyzeError
 			for _, f := range params.Files {
 				// Match by exact basename only, as requested
 				name := filepath.Base(f.Path)
 
 				data, err := readFile(f)
 				if err != nil {
+					errs = append(errs, analyzeError{File: f.Path, Stage: stageRead, Code: codeFileReadFailed, Message: err.Error()})
 					continue
 				}
 
-				var hits []depHit
-				switch name {
-				case "requirements.txt":
-					hits = parseRequirements(data)
-				case "go.mod":
-					hits = parseGoMod(data)
-				case "package.json":
-					hits = parsePackageJSON(data)
-				case "package-lock.json":
-					hits = parsePackageLockJSON(data)
-				case "Cargo.lock":
-					hits = parseCargoLock(data)
-				case "composer.lock":
-					hits = parseComposerLock(data)
-				case "Gemfile.lock":
-					hits = parseGemfileLock(data)
-				case "pom.xml":
-					hits = parsePomXML(data)
-				default:
+				hits, ok, err := parseDepFile(name, data)
+				if !ok {
 					continue
 				}
+				if err != nil {
+					errs = append(errs, analyzeError{File: f.Path, Stage: stageParse, Code: codeParseFailed, Message: err.Error()})
+					continue
+				}
+				perFile = append(perFile, fileHits{path: f.Path, hits: hits})
+
+				// file.graph shares this structural graph rather than
+				// re-parsing; affected_by edges are layered on below once
+				// OSV resolution has run.
+				graphCache[f.Path] = buildFileGraph(f.Path, name, data, hits)
+			}
 
-				for _, hit := range hits {
-					ids, err := queryOSVCached(hit.Dep)
-					if err != nil {
-						// Log and continue
-						fmt.Fprintf(os.Stderr, "Error querying OSV for %s: %v\n", hit.Dep.Name, err)
+			// Unpinned deps (package.json carets/tildes, bare requirements.txt
+			// specs) resolve against an adjacent lockfile's pinned version
+			// when this batch has one, falling back to range matching.
+			locked := collectLockfileVersions(perFile)
+
+			// Resolve every distinct (not already cached) dependency in one
+			// batched, bounded-concurrency pass instead of one request per
+			// hit, so a large lockfile doesn't serialize behind OSV latency.
+			var pending []PackageRequest
+			seen := map[string]bool{}
+			for _, pf := range perFile {
+				for _, hit := range pf.hits {
+					resolved := resolveVersion(hit, locked)
+					key := packageKey(hit.Dep.Ecosystem, hit.Dep.Name, resolved.Version)
+					if seen[key] {
 						continue
 					}
-					for _, id := range ids {
-						sev := severityFromID(id)
+					seen[key] = true
+					if _, cached := vulnCache[key]; cached {
+						continue
+					}
+					pending = append(pending, PackageRequest{
+						Ecosystem: hit.Dep.Ecosystem,
+						Name:      hit.Dep.Name,
+						Version:   resolved.Version,
+					})
+				}
+			}
+			if len(pending) > 0 {
+				resolved, err := resolveEntries(ctx, pending, osvWorkers)
+				for key, entries := range resolved {
+					vulnCache[key] = entries
+				}
+				if err != nil {
+					errs = append(errs, analyzeError{Stage: stageQuery, Code: classifyOSVError(err), Message: err.Error()})
+				}
+			}
+
+			var findings []findingOut
+			for _, pf := range perFile {
+				for _, hit := range pf.hits {
+					resolved := resolveVersion(hit, locked)
+					key := packageKey(hit.Dep.Ecosystem, hit.Dep.Name, resolved.Version)
+					for _, entry := range vulnCache[key] {
+						if resolved.ViaRange && !entryCoversSpec(entry, hit.Dep.Ecosystem, hit.Dep.Name, resolved.Spec) {
+							continue
+						}
+
+						sev := severityFromEntry(entry)
 						rule := ruleForEco(hit.Dep.Ecosystem)
-						msg := fmt.Sprintf("%s %s vulnerable: %s", hit.Dep.Name, hit.Dep.Version, id)
-						rem := "Update to a secure version (check OSV)"
+						msg := fmt.Sprintf("%s %s vulnerable: %s%s", hit.Dep.Name, hit.Dep.Version, entry.ID, aliasSuffix(entry))
+						if hit.Unpinned {
+							if resolved.ViaRange {
+								msg += " (matched via range)"
+							} else {
+								msg += " (matched via pinned version)"
+							}
+						}
+
+						var rem *string
+						if fixed, ok := firstFixedVersion(entry, hit.Dep.Ecosystem, hit.Dep.Name); ok {
+							r := fmt.Sprintf("Upgrade %s to %s or later", hit.Dep.Name, fixed)
+							rem = &r
+						} else {
+							r := "Update to a secure version (check OSV)"
+							rem = &r
+						}
+
+						var fix *string
+						if url, ok := primaryReferenceURL(entry); ok {
+							fix = &url
+						}
+
 						findings = append(findings, findingOut{
-							Id:        id,
-							RuleId:    rule,
-							Severity:  sev,
-							File:      f.Path,
-							Line:      hit.Line,
-							Column:    1,
-							Excerpt:   hit.Excerpt,
-							Message:   msg,
-							Remediation: &rem,
+							Id:          entry.ID,
+							RuleId:      rule,
+							Severity:    sev,
+							File:        pf.path,
+							Line:        hit.Line,
+							Column:      1,
+							Excerpt:     hit.Excerpt,
+							Message:     msg,
+							Remediation: rem,
+							Fix:         fix,
 						})
+
+						g := graphCache[pf.path]
+						addVulnEdges(&g, hit.Dep.Ecosystem, hit.Dep.Name, hit.Dep.Version, entry, sev)
+						graphCache[pf.path] = g
 					}
 				}
 			}
-			send(req.ID, findings, nil)
+			if params.Strict && len(errs) > 0 {
+				send(req.ID, nil, &errorObj{Code: 1003, Message: "file.analyze failed in strict mode", Data: errs})
+				continue
+			}
+			send(req.ID, analyzeResult{Findings: findings, Errors: errs}, nil)
+		case "file.graph":
+			var params fileAnalyzeParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				send(req.ID, nil, &errorObj{Code: 1001, Message: "invalid params"})
+				continue
+			}
+
+			var graphs []fileGraph
+			for _, f := range params.Files {
+				if g, ok := graphCache[f.Path]; ok {
+					graphs = append(graphs, g)
+					continue
+				}
+
+				name := filepath.Base(f.Path)
+				data, err := readFile(f)
+				if err != nil {
+					continue
+				}
+				hits, _, _ := parseDepFile(name, data)
+				g := buildFileGraph(f.Path, name, data, hits)
+				graphCache[f.Path] = g
+				graphs = append(graphs, g)
+			}
+			send(req.ID, map[string]interface{}{"graphs": graphs}, nil)
 		case "plugin.ping":
 			send(req.ID, "pong", nil)
 		case "plugin.shutdown":
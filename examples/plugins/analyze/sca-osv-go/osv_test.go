@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryOSVSourceByPackage(t *testing.T) {
+	lodashEntry := Entry{ID: "GHSA-1", Summary: "prototype pollution"}
+	src := &memoryOSVSource{entries: map[string][]Entry{
+		packageKey("npm", "lodash", "4.17.15"): {lodashEntry},
+	}}
+
+	entries, err := src.ByPackage(context.Background(), PackageRequest{Ecosystem: "npm", Name: "lodash", Version: "4.17.15"})
+	if err != nil {
+		t.Fatalf("ByPackage: %v", err)
+	}
+	if !reflect.DeepEqual(entries, []Entry{lodashEntry}) {
+		t.Errorf("ByPackage(4.17.15) = %+v, want %+v", entries, []Entry{lodashEntry})
+	}
+
+	entries, err = src.ByPackage(context.Background(), PackageRequest{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"})
+	if err != nil {
+		t.Fatalf("ByPackage: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ByPackage(4.17.21) = %+v, want no entries for an unlisted version", entries)
+	}
+}
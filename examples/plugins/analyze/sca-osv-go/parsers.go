@@ -0,0 +1,287 @@
+package main
+
+// Parser registry for dependency manifests/lockfiles. Each Parser claims a
+// basename and turns the file's bytes into depHits; parseDepFile dispatches
+// to whichever one matches instead of the plugin growing another branch of
+// a single switch every time a new ecosystem is added.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Parser recognizes one kind of dependency file and extracts its deps. An
+// error from Parse means the file matched but couldn't be understood (e.g.
+// malformed JSON/TOML), which file.analyze reports as a PARSE_FAILED error
+// rather than silently treating the file as dependency-free.
+type Parser interface {
+	Match(basename string) bool
+	Parse(data []byte) ([]depHit, error)
+}
+
+// parserFunc adapts a match predicate and a parse function into a Parser.
+type parserFunc struct {
+	match func(string) bool
+	parse func([]byte) ([]depHit, error)
+}
+
+func (p parserFunc) Match(basename string) bool         { return p.match(basename) }
+func (p parserFunc) Parse(data []byte) ([]depHit, error) { return p.parse(data) }
+
+// exactNameParser builds a Parser that matches a single, exact basename.
+func exactNameParser(name string, parse func([]byte) ([]depHit, error)) Parser {
+	return parserFunc{match: func(b string) bool { return b == name }, parse: parse}
+}
+
+// parsers lists every dependency file this plugin understands, checked in
+// order by parseDepFile.
+var parsers = []Parser{
+	exactNameParser("requirements.txt", parseRequirements),
+	exactNameParser("go.mod", parseGoMod),
+	exactNameParser("package.json", parsePackageJSON),
+	exactNameParser("package-lock.json", parsePackageLockJSON),
+	exactNameParser("Cargo.lock", parseCargoLock),
+	exactNameParser("composer.lock", parseComposerLock),
+	exactNameParser("Gemfile.lock", parseGemfileLock),
+	exactNameParser("pom.xml", parsePomXML),
+	exactNameParser("poetry.lock", parsePoetryLock),
+	exactNameParser("Pipfile.lock", parsePipfileLock),
+	exactNameParser("yarn.lock", parseYarnLock),
+	exactNameParser("pnpm-lock.yaml", parsePnpmLock),
+	exactNameParser("go.sum", parseGoSum),
+	exactNameParser("gradle.lockfile", parseGradleLockfile),
+}
+
+// parseDepFile dispatches to the registered Parser for name. ok is false
+// when the file isn't a dependency manifest this plugin understands; err is
+// set when it is but its contents couldn't be parsed.
+func parseDepFile(name string, data []byte) (hits []depHit, ok bool, err error) {
+	for _, p := range parsers {
+		if p.Match(name) {
+			hits, err = p.Parse(data)
+			return hits, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// lineOf returns the 1-based line number of the first line in data
+// containing a literal match for key, or 1 if none does.
+func lineOf(data []byte, key string) int {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.Contains(scanner.Text(), key) {
+			return line
+		}
+	}
+	return 1
+}
+
+// parsePoetryLock reads poetry.lock's TOML [[package]] blocks (PyPI).
+func parsePoetryLock(data []byte) ([]depHit, error) {
+	var hits []depHit
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inPkg := false
+	line := 0
+	pkgLine := 0
+	name := ""
+	ver := ""
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "[[package]]" {
+			inPkg = true
+			pkgLine = line
+			name = ""
+			ver = ""
+			continue
+		}
+		if strings.HasPrefix(raw, "[") && raw != "[[package]]" {
+			inPkg = false
+		}
+		if !inPkg {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(raw, "name = "):
+			name = strings.Trim(raw[len("name = "):], "\" ")
+		case strings.HasPrefix(raw, "version = "):
+			ver = strings.Trim(raw[len("version = "):], "\" ")
+		}
+		if name != "" && ver != "" {
+			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "PyPI"}, Line: pkgLine, Excerpt: name + " = " + ver})
+			name = ""
+			ver = ""
+		}
+	}
+	return hits, scanner.Err()
+}
+
+// parsePipfileLock reads Pipfile.lock's "default" and "develop" dependency
+// maps (PyPI), stripping the leading "==" pin operator from each version.
+func parsePipfileLock(data []byte) ([]depHit, error) {
+	type pkgSpec struct {
+		Version string `json:"version"`
+	}
+	var raw struct {
+		Default map[string]pkgSpec `json:"default"`
+		Develop map[string]pkgSpec `json:"develop"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var hits []depHit
+	add := func(name string, spec pkgSpec) {
+		ver := strings.TrimPrefix(spec.Version, "==")
+		if ver == "" {
+			return
+		}
+		hits = append(hits, depHit{
+			Dep:     dependency{Name: name, Version: ver, Ecosystem: "PyPI"},
+			Line:    lineOf(data, "\""+name+"\""),
+			Excerpt: name + ": " + spec.Version,
+		})
+	}
+	for name, spec := range raw.Default {
+		add(name, spec)
+	}
+	for name, spec := range raw.Develop {
+		add(name, spec)
+	}
+	return hits, nil
+}
+
+// parseYarnLock reads yarn.lock's classic v1 format: a header line listing
+// one or more "name@spec" entries, followed by an indented `version "x.y.z"`
+// line (npm). The name/spec split uses the last "@" so scoped packages like
+// "@babel/core@^7.0.0" are handled correctly.
+func parseYarnLock(data []byte) ([]depHit, error) {
+	var hits []depHit
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	headerLine := 0
+	name := ""
+	for scanner.Scan() {
+		line++
+		raw := scanner.Text()
+		switch {
+		case raw != "" && !strings.HasPrefix(raw, " ") && !strings.HasPrefix(raw, "#") && strings.HasSuffix(raw, ":"):
+			headerLine = line
+			name = ""
+			first := strings.TrimSuffix(strings.SplitN(raw, ",", 2)[0], ":")
+			first = strings.Trim(first, "\"")
+			if i := strings.LastIndex(first, "@"); i > 0 {
+				name = first[:i]
+			}
+		case name != "" && strings.HasPrefix(strings.TrimSpace(raw), "version "):
+			ver := strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "version")), "\" ")
+			hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: headerLine, Excerpt: name + "@" + ver})
+			name = ""
+		}
+	}
+	return hits, scanner.Err()
+}
+
+// parsePnpmLock reads pnpm-lock.yaml's "packages:" section, whose keys are
+// either "/name/version" or "name@version" (npm).
+func parsePnpmLock(data []byte) ([]depHit, error) {
+	var hits []depHit
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	inPackages := false
+	for scanner.Scan() {
+		line++
+		raw := scanner.Text()
+		trimmed := strings.TrimRight(raw, " ")
+		if trimmed == "" {
+			continue
+		}
+		if !strings.HasPrefix(raw, " ") {
+			inPackages = trimmed == "packages:"
+			continue
+		}
+		if !inPackages {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if indent != 2 {
+			continue
+		}
+		key := strings.TrimSpace(trimmed)
+		key = strings.TrimSuffix(key, ":")
+		key = strings.Trim(key, "'\"")
+		name, ver, ok := parsePnpmKey(key)
+		if !ok {
+			continue
+		}
+		hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "npm"}, Line: line, Excerpt: key})
+	}
+	return hits, scanner.Err()
+}
+
+// parsePnpmKey splits a pnpm-lock.yaml package key into name and version,
+// accepting both the "/name/version" and "name@version" forms pnpm has used.
+func parsePnpmKey(key string) (name, version string, ok bool) {
+	key = strings.TrimPrefix(key, "/")
+	if i := strings.LastIndex(key, "@"); i > 0 {
+		return key[:i], key[i+1:], true
+	}
+	if i := strings.LastIndex(key, "/"); i > 0 {
+		return key[:i], key[i+1:], true
+	}
+	return "", "", false
+}
+
+// parseGoSum reads go.sum's "<module> <version>[/go.mod] h1:..." lines,
+// deduplicating the /go.mod hash line each module also gets (Go ecosystem).
+func parseGoSum(data []byte) ([]depHit, error) {
+	var hits []depHit
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		module, ver := fields[0], fields[1]
+		ver = strings.TrimSuffix(ver, "/go.mod")
+		key := module + "@" + ver
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		hits = append(hits, depHit{Dep: dependency{Name: module, Version: ver, Ecosystem: "Go"}, Line: line, Excerpt: module + " " + ver})
+	}
+	return hits, scanner.Err()
+}
+
+// parseGradleLockfile reads gradle.lockfile's
+// "group:artifact:version=configurations" lines (Maven), skipping comments
+// and the trailing "empty=..." marker line.
+func parseGradleLockfile(data []byte) ([]depHit, error) {
+	var hits []depHit
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || strings.HasPrefix(raw, "#") || strings.HasPrefix(raw, "empty=") {
+			continue
+		}
+		coord := strings.SplitN(raw, "=", 2)[0]
+		parts := strings.Split(coord, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		group, artifact, ver := parts[0], parts[1], parts[2]
+		name := group + ":" + artifact
+		hits = append(hits, depHit{Dep: dependency{Name: name, Version: ver, Ecosystem: "Maven"}, Line: line, Excerpt: raw})
+	}
+	return hits, scanner.Err()
+}
@@ -0,0 +1,154 @@
+package main
+
+import "testing"
+
+func TestParsePoetryLock(t *testing.T) {
+	data := []byte(`[[package]]
+name = "requests"
+version = "2.31.0"
+
+[[package]]
+name = "urllib3"
+version = "2.0.7"
+`)
+	hits, err := parsePoetryLock(data)
+	if err != nil {
+		t.Fatalf("parsePoetryLock: %v", err)
+	}
+	want := map[string]string{"requests": "2.31.0", "urllib3": "2.0.7"}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for _, h := range hits {
+		if h.Dep.Ecosystem != "PyPI" {
+			t.Errorf("%s: ecosystem = %q, want PyPI", h.Dep.Name, h.Dep.Ecosystem)
+		}
+		if want[h.Dep.Name] != h.Dep.Version {
+			t.Errorf("%s: version = %q, want %q", h.Dep.Name, h.Dep.Version, want[h.Dep.Name])
+		}
+		if h.Line == 0 {
+			t.Errorf("%s: got Line 0, want a real line number", h.Dep.Name)
+		}
+	}
+}
+
+func TestParsePipfileLock(t *testing.T) {
+	data := []byte(`{
+  "default": {
+    "requests": {"version": "==2.31.0"}
+  },
+  "develop": {
+    "pytest": {"version": "==7.4.0"}
+  }
+}`)
+	hits, err := parsePipfileLock(data)
+	if err != nil {
+		t.Fatalf("parsePipfileLock: %v", err)
+	}
+	want := map[string]string{"requests": "2.31.0", "pytest": "7.4.0"}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for _, h := range hits {
+		if h.Dep.Ecosystem != "PyPI" {
+			t.Errorf("%s: ecosystem = %q, want PyPI", h.Dep.Name, h.Dep.Ecosystem)
+		}
+		if want[h.Dep.Name] != h.Dep.Version {
+			t.Errorf("%s: version = %q, want %q (== prefix should be stripped)", h.Dep.Name, h.Dep.Version, want[h.Dep.Name])
+		}
+	}
+}
+
+func TestParseYarnLock(t *testing.T) {
+	data := []byte(`"@babel/core@^7.0.0", "@babel/core@^7.1.0":
+  version "7.22.0"
+
+lodash@^4.17.21:
+  version "4.17.21"
+`)
+	hits, err := parseYarnLock(data)
+	if err != nil {
+		t.Fatalf("parseYarnLock: %v", err)
+	}
+	want := map[string]string{"@babel/core": "7.22.0", "lodash": "4.17.21"}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for _, h := range hits {
+		if h.Dep.Ecosystem != "npm" {
+			t.Errorf("%s: ecosystem = %q, want npm", h.Dep.Name, h.Dep.Ecosystem)
+		}
+		if want[h.Dep.Name] != h.Dep.Version {
+			t.Errorf("%s: version = %q, want %q", h.Dep.Name, h.Dep.Version, want[h.Dep.Name])
+		}
+	}
+}
+
+func TestParsePnpmLock(t *testing.T) {
+	data := []byte(`lockfileVersion: '6.0'
+
+packages:
+
+  /lodash@4.17.21:
+    resolution: {integrity: sha512-abc}
+
+  /left-pad@1.3.0:
+    resolution: {integrity: sha512-def}
+`)
+	hits, err := parsePnpmLock(data)
+	if err != nil {
+		t.Fatalf("parsePnpmLock: %v", err)
+	}
+	want := map[string]string{"lodash": "4.17.21", "left-pad": "1.3.0"}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for _, h := range hits {
+		if h.Dep.Ecosystem != "npm" {
+			t.Errorf("%s: ecosystem = %q, want npm", h.Dep.Name, h.Dep.Ecosystem)
+		}
+		if want[h.Dep.Name] != h.Dep.Version {
+			t.Errorf("%s: version = %q, want %q", h.Dep.Name, h.Dep.Version, want[h.Dep.Name])
+		}
+	}
+}
+
+func TestParseGoSum(t *testing.T) {
+	data := []byte(`github.com/pkg/errors v0.9.1 h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4=
+github.com/pkg/errors v0.9.1/go.mod h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0=
+`)
+	hits, err := parseGoSum(data)
+	if err != nil {
+		t.Fatalf("parseGoSum: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1 (the /go.mod line should dedup away): %+v", len(hits), hits)
+	}
+	if hits[0].Dep.Name != "github.com/pkg/errors" || hits[0].Dep.Version != "v0.9.1" {
+		t.Errorf("got %+v, want github.com/pkg/errors v0.9.1", hits[0].Dep)
+	}
+	if hits[0].Dep.Ecosystem != "Go" {
+		t.Errorf("ecosystem = %q, want Go", hits[0].Dep.Ecosystem)
+	}
+}
+
+func TestParseGradleLockfile(t *testing.T) {
+	data := []byte(`#Gradle dependency lockfile
+com.google.guava:guava:31.1-jre=compileClasspath,runtimeClasspath
+empty=
+`)
+	hits, err := parseGradleLockfile(data)
+	if err != nil {
+		t.Fatalf("parseGradleLockfile: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1: %+v", len(hits), hits)
+	}
+	h := hits[0]
+	if h.Dep.Name != "com.google.guava:guava" || h.Dep.Version != "31.1-jre" {
+		t.Errorf("got %+v, want com.google.guava:guava 31.1-jre", h.Dep)
+	}
+	if h.Dep.Ecosystem != "Maven" {
+		t.Errorf("ecosystem = %q, want Maven", h.Dep.Ecosystem)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+// Resolves an unpinned dependency spec (package.json's "^1.2.3", a bare
+// requirements.txt ">=1.2.3", ...) to something OSV can be queried against:
+// the pinned version from an adjacent lockfile when the batch has one, or
+// the raw spec for local SemVer range matching otherwise.
+
+import "path/filepath"
+
+// lockfileVersions maps dependency name to pinned version, built from any
+// lockfile present in the same file.analyze batch.
+type lockfileVersions struct {
+	npm  map[string]string // from package-lock.json
+	pypi map[string]string // from Pipfile.lock
+}
+
+// collectLockfileVersions scans the files already parsed in this batch for
+// lockfiles and indexes the pinned versions they contain.
+func collectLockfileVersions(perFile []fileHits) lockfileVersions {
+	lv := lockfileVersions{npm: map[string]string{}, pypi: map[string]string{}}
+	for _, pf := range perFile {
+		var dst map[string]string
+		switch filepath.Base(pf.path) {
+		case "package-lock.json":
+			dst = lv.npm
+		case "Pipfile.lock":
+			dst = lv.pypi
+		default:
+			continue
+		}
+		for _, h := range pf.hits {
+			dst[h.Dep.Name] = h.Dep.Version
+		}
+	}
+	return lv
+}
+
+func (lv lockfileVersions) lookup(ecosystem, name string) (string, bool) {
+	switch ecosystem {
+	case "npm":
+		v, ok := lv.npm[name]
+		return v, ok
+	case "PyPI":
+		v, ok := lv.pypi[name]
+		return v, ok
+	default:
+		return "", false
+	}
+}
+
+// resolvedDep is how a depHit should be looked up against OSV: Version set
+// and ViaRange false for an already-pinned (or lockfile-resolved)
+// dependency, or Version empty and ViaRange true to fetch every known vuln
+// for the package and filter locally by Spec.
+type resolvedDep struct {
+	Version  string
+	Spec     string
+	ViaRange bool
+}
+
+// resolveVersion decides how to look up OSV vulnerabilities for a
+// dependency hit: the pinned version from an adjacent lockfile when one
+// covers this dependency, otherwise its raw spec for range matching.
+// Already-pinned hits (lockfile parsers, exact "==" requirements) pass
+// through unchanged.
+func resolveVersion(hit depHit, lv lockfileVersions) resolvedDep {
+	if !hit.Unpinned {
+		return resolvedDep{Version: hit.Dep.Version}
+	}
+	if v, ok := lv.lookup(hit.Dep.Ecosystem, hit.Dep.Name); ok {
+		return resolvedDep{Version: v}
+	}
+	return resolvedDep{Spec: hit.Spec, ViaRange: true}
+}
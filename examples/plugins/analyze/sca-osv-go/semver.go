@@ -0,0 +1,219 @@
+package main
+
+// Minimal SemVer parsing and range evaluation, used to match an unpinned
+// dependency spec (package.json's "^"/"~"/">=", requirements.txt's
+// "~="/">="/etc) against OSV's affected[].ranges events without pulling in
+// an external semver library.
+
+import (
+	"strconv"
+	"strings"
+)
+
+type semver struct {
+	major, minor, patch int
+}
+
+// parseSemverLoose parses "v1.2.3", "1.2", "1", ignoring any
+// pre-release/build suffix and defaulting missing components to 0.
+func parseSemverLoose(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return semver{}, false
+	}
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.SplitN(s, ".", 3)
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	return semver{major: nums[0], minor: nums[1], patch: nums[2]}, true
+}
+
+func compareSemver(a, b semver) int {
+	switch {
+	case a.major != b.major:
+		return cmpInt(a.major, b.major)
+	case a.minor != b.minor:
+		return cmpInt(a.minor, b.minor)
+	default:
+		return cmpInt(a.patch, b.patch)
+	}
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// semverRange is the set of versions a dependency spec allows: either a
+// single exact version, or a half-open [min, max) interval (unbounded
+// above when hasMax is false).
+type semverRange struct {
+	min    semver
+	max    semver
+	hasMax bool
+	exact  bool
+}
+
+// specRange translates a dependency spec into the range of versions it
+// allows. Supports npm's "^"/"~"/">=", PyPI's "~="/">="/"==", and a bare
+// exact version.
+func specRange(spec string) (semverRange, bool) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "^"):
+		v, ok := parseSemverLoose(spec[1:])
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v, max: caretMax(v), hasMax: true}, true
+	case strings.HasPrefix(spec, "~="):
+		v, ok := parseSemverLoose(spec[2:])
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v, max: tildeMax(v), hasMax: true}, true
+	case strings.HasPrefix(spec, "~"):
+		v, ok := parseSemverLoose(spec[1:])
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v, max: tildeMax(v), hasMax: true}, true
+	case strings.HasPrefix(spec, ">="):
+		v, ok := parseSemverLoose(spec[2:])
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v}, true
+	case strings.HasPrefix(spec, "=="):
+		v, ok := parseSemverLoose(spec[2:])
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v, exact: true}, true
+	default:
+		v, ok := parseSemverLoose(spec)
+		if !ok {
+			return semverRange{}, false
+		}
+		return semverRange{min: v, exact: true}, true
+	}
+}
+
+// caretMax returns the exclusive upper bound for npm's "^" operator: the
+// next version that would change the leftmost non-zero component.
+func caretMax(v semver) semver {
+	switch {
+	case v.major > 0:
+		return semver{major: v.major + 1}
+	case v.minor > 0:
+		return semver{minor: v.minor + 1}
+	default:
+		return semver{patch: v.patch + 1}
+	}
+}
+
+// tildeMax returns the exclusive upper bound for "~"/"~=": the next minor
+// version.
+func tildeMax(v semver) semver {
+	return semver{major: v.major, minor: v.minor + 1}
+}
+
+// rangeCoversVulnerable reports whether spec overlaps the half-open
+// [introduced, fixed) interval reported by one OSV range event pair.
+func rangeCoversVulnerable(spec semverRange, introduced semver, hasFixed bool, fixed semver) bool {
+	if spec.exact {
+		v := spec.min
+		if compareSemver(v, introduced) < 0 {
+			return false
+		}
+		return !hasFixed || compareSemver(v, fixed) < 0
+	}
+	if spec.hasMax && compareSemver(spec.max, introduced) <= 0 {
+		return false
+	}
+	if hasFixed && compareSemver(fixed, spec.min) <= 0 {
+		return false
+	}
+	return true
+}
+
+// rangeMatchesSpec walks one OSV Range's introduced/fixed events, pairing
+// each "introduced" with the next "fixed" (or "last_affected", or the end
+// of the list for an open-ended range), and reports whether spec overlaps
+// any of the resulting vulnerable intervals.
+func rangeMatchesSpec(r Range, spec semverRange) bool {
+	var introduced semver
+	haveIntroduced := false
+	for _, ev := range r.Events {
+		switch {
+		case ev.Introduced != "":
+			v, ok := parseSemverLoose(ev.Introduced)
+			if !ok {
+				v = semver{}
+			}
+			introduced, haveIntroduced = v, true
+		case ev.Fixed != "":
+			if !haveIntroduced {
+				continue
+			}
+			if fixed, ok := parseSemverLoose(ev.Fixed); ok && rangeCoversVulnerable(spec, introduced, true, fixed) {
+				return true
+			}
+			haveIntroduced = false
+		case ev.LastAffected != "":
+			if !haveIntroduced {
+				continue
+			}
+			if last, ok := parseSemverLoose(ev.LastAffected); ok {
+				fixed := semver{major: last.major, minor: last.minor, patch: last.patch + 1}
+				if rangeCoversVulnerable(spec, introduced, true, fixed) {
+					return true
+				}
+			}
+			haveIntroduced = false
+		}
+	}
+	if haveIntroduced && rangeCoversVulnerable(spec, introduced, false, semver{}) {
+		return true
+	}
+	return false
+}
+
+// entryCoversSpec reports whether e actually affects the given unpinned
+// spec, by evaluating its affected[].ranges locally rather than trusting a
+// version-less OSV query to have already narrowed things down.
+func entryCoversSpec(e Entry, ecosystem, name, rawSpec string) bool {
+	spec, ok := specRange(rawSpec)
+	if !ok {
+		// Spec we don't understand: don't drop a potentially real finding.
+		return true
+	}
+	for _, a := range e.Affected {
+		if a.Package.Ecosystem != ecosystem || a.Package.Name != name {
+			continue
+		}
+		if len(a.Ranges) == 0 {
+			return true
+		}
+		for _, r := range a.Ranges {
+			if rangeMatchesSpec(r, spec) {
+				return true
+			}
+		}
+	}
+	return false
+}
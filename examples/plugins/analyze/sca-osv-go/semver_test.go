@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestSpecRange(t *testing.T) {
+	cases := []struct {
+		spec string
+		ok   bool
+	}{
+		{"^1.2.3", true},
+		{"~1.2.3", true},
+		{"~=1.2.3", true},
+		{">=1.2.3", true},
+		{"==1.2.3", true},
+		{"1.2.3", true},
+		{"not-a-version", false},
+	}
+	for _, c := range cases {
+		_, ok := specRange(c.spec)
+		if ok != c.ok {
+			t.Errorf("specRange(%q) ok = %v, want %v", c.spec, ok, c.ok)
+		}
+	}
+}
+
+func TestRangeMatchesSpec(t *testing.T) {
+	r := Range{Type: "SEMVER", Events: []RangeEvent{
+		{Introduced: "1.0.0"},
+		{Fixed: "1.5.0"},
+	}}
+
+	inRange, ok := specRange("^1.2.3")
+	if !ok {
+		t.Fatal("specRange(^1.2.3) failed")
+	}
+	if !rangeMatchesSpec(r, inRange) {
+		t.Errorf("expected ^1.2.3 to fall within [1.0.0, 1.5.0)")
+	}
+
+	outOfRange, ok := specRange("^2.0.0")
+	if !ok {
+		t.Fatal("specRange(^2.0.0) failed")
+	}
+	if rangeMatchesSpec(r, outOfRange) {
+		t.Errorf("expected ^2.0.0 to fall outside [1.0.0, 1.5.0)")
+	}
+}
+
+func TestEntryCoversSpec(t *testing.T) {
+	var affected Affected
+	affected.Package.Ecosystem = "npm"
+	affected.Package.Name = "left-pad"
+	affected.Ranges = []Range{{Type: "SEMVER", Events: []RangeEvent{
+		{Introduced: "0.0.0"},
+		{Fixed: "1.0.0"},
+	}}}
+	e := Entry{Affected: []Affected{affected}}
+
+	if !entryCoversSpec(e, "npm", "left-pad", "^0.5.0") {
+		t.Errorf("expected ^0.5.0 to be covered by [0.0.0, 1.0.0)")
+	}
+	if entryCoversSpec(e, "npm", "left-pad", "^1.0.0") {
+		t.Errorf("expected ^1.0.0 (at the fixed version) not to be covered")
+	}
+	if entryCoversSpec(e, "npm", "some-other-pkg", "^0.5.0") {
+		t.Errorf("expected a non-matching package name not to be covered")
+	}
+}
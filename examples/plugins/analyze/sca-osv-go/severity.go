@@ -0,0 +1,221 @@
+package main
+
+// Derives findingOut fields (severity, remediation, fix link, message
+// suffix) from a full OSV Entry, rather than guessing from the ID prefix.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// severityFromEntry maps the highest CVSS base score found on the entry to
+// a bucket. It falls back to the old ID-prefix heuristic when no base score
+// can be derived from the entry: none is present (some mirrors omit it), or
+// every one is a vector cvssBaseScore does not score.
+func severityFromEntry(e Entry) string {
+	score, ok := highestCVSSScore(e)
+	if !ok {
+		return severityFromID(e.ID)
+	}
+	switch {
+	case score >= 9.0:
+		return "CRITICAL"
+	case score >= 7.0:
+		return "HIGH"
+	case score >= 4.0:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// highestCVSSScore returns the highest CVSS base score across the entry's
+// severity[] vectors and affected[].database_specific.cvss_score fields.
+func highestCVSSScore(e Entry) (float64, bool) {
+	var best float64
+	found := false
+	consider := func(v float64) {
+		if !found || v > best {
+			best = v
+			found = true
+		}
+	}
+
+	for _, s := range e.Severity {
+		if v, err := cvssBaseScore(s.Score); err == nil {
+			consider(v)
+		}
+	}
+	for _, a := range e.Affected {
+		raw, ok := a.DatabaseSpecific["cvss_score"]
+		if !ok {
+			continue
+		}
+		switch t := raw.(type) {
+		case float64:
+			consider(t)
+		case string:
+			if v, err := strconv.ParseFloat(t, 64); err == nil {
+				consider(v)
+			}
+		}
+	}
+	return best, found
+}
+
+// cvssBaseScore extracts a numeric base score from a severity score field.
+// Some mirrors store a bare number; OSV's own CVSS_V3/CVSS_V4 entries (and
+// virtually every GHSA-sourced one) store a full vector string instead. A
+// v3.x vector, e.g. "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", is parsed
+// and scored with the standard CVSS v3.x base-score formula; any other
+// vector (including "CVSS:4.0/...") is not scored and returns an error.
+func cvssBaseScore(score string) (float64, error) {
+	score = strings.TrimSpace(score)
+	if v, err := strconv.ParseFloat(score, 64); err == nil {
+		return v, nil
+	}
+	if strings.HasPrefix(score, "CVSS:3.") {
+		return cvss3BaseScore(score)
+	}
+	return 0, fmt.Errorf("cannot derive base score from vector %q", score)
+}
+
+// cvss3Weights are the CVSS v3.x metric weights used by the base-score
+// formula (CVSS v3.1 spec section 7.1).
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	// PR depends on Scope; both tables are keyed here and picked by scope.
+	"PR/U": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"PR/C": {"N": 0.85, "L": 0.68, "H": 0.5},
+}
+
+// cvss3BaseScore computes the CVSS v3.x base score from a full vector
+// string, per the formula in the CVSS v3.1 specification.
+func cvss3BaseScore(vector string) (float64, error) {
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+
+	scope := metrics["S"]
+	prTable := "PR/U"
+	if scope == "C" {
+		prTable = "PR/C"
+	}
+
+	weight := func(metric string) (float64, error) {
+		table := cvss3Weights[metric]
+		if metric == "PR" {
+			table = cvss3Weights[prTable]
+		}
+		v, ok := table[metrics[metric]]
+		if !ok {
+			return 0, fmt.Errorf("cvss vector %q: missing or unknown %s", vector, metric)
+		}
+		return v, nil
+	}
+
+	av, err := weight("AV")
+	if err != nil {
+		return 0, err
+	}
+	ac, err := weight("AC")
+	if err != nil {
+		return 0, err
+	}
+	pr, err := weight("PR")
+	if err != nil {
+		return 0, err
+	}
+	ui, err := weight("UI")
+	if err != nil {
+		return 0, err
+	}
+	conf, err := weight("C")
+	if err != nil {
+		return 0, err
+	}
+	integ, err := weight("I")
+	if err != nil {
+		return 0, err
+	}
+	avail, err := weight("A")
+	if err != nil {
+		return 0, err
+	}
+
+	iss := 1 - (1-conf)*(1-integ)*(1-avail)
+	var impact float64
+	if scope == "C" {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	exploitability := 8.22 * av * ac * pr * ui
+
+	if scope == "C" {
+		return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return cvssRoundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// cvssRoundUp implements CVSS's "Roundup" function: round up to the nearest
+// 0.1, working in integer hundred-thousandths to avoid float drift.
+func cvssRoundUp(v float64) float64 {
+	intInput := int(math.Round(v * 100000))
+	if intInput%10000 == 0 {
+		return float64(intInput) / 100000
+	}
+	return float64(intInput/10000+1) / 10
+}
+
+// firstFixedVersion returns the first "fixed" event found across the
+// ranges of the Affected entry matching ecosystem/name, used to build the
+// Remediation string. A compound advisory can list several affected
+// packages, so this must not consider ranges belonging to any other one.
+func firstFixedVersion(e Entry, ecosystem, name string) (string, bool) {
+	for _, a := range e.Affected {
+		if a.Package.Ecosystem != ecosystem || a.Package.Name != name {
+			continue
+		}
+		for _, r := range a.Ranges {
+			for _, ev := range r.Events {
+				if ev.Fixed != "" {
+					return ev.Fixed, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// primaryReferenceURL returns the first reference URL on the entry, if any.
+func primaryReferenceURL(e Entry) (string, bool) {
+	if len(e.References) == 0 {
+		return "", false
+	}
+	return e.References[0].URL, true
+}
+
+// aliasSuffix renders the entry's aliases (e.g. CVE-...) for inclusion in
+// the finding message, or "" when there are none.
+func aliasSuffix(e Entry) string {
+	if len(e.Aliases) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(e.Aliases, ", ") + ")"
+}